@@ -1,39 +1,65 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"regexp"
 	"strings"
-	"unicode"
+	"unicode/utf8"
+
+	"github.com/garmir/b64d/pkg/b64d"
 )
 
 const (
-	maxFileSize   = 100 * 1024 * 1024 // 100MB max file size
-	maxMatches    = 10000              // Maximum number of matches to process
-	minB64Length  = 4                  // Minimum base64 string length
-	chunkSize     = 64 * 1024          // Read buffer size
+	maxFileSize  = 100 * 1024 * 1024 // 100MB max file size (ignored in -stream mode)
+	minB64Length = 4                 // Minimum base64 string length
 )
 
 type Config struct {
-	urlSafe    bool
-	minLength  int
-	maxSize    int64
-	verbose    bool
-	showOffset bool
-}
-
-var (
-	config Config
-	// Standard base64 pattern
-	stdB64Pattern = regexp.MustCompile(`[A-Za-z0-9+/]{4,}={0,2}`)
-	// URL-safe base64 pattern
-	urlB64Pattern = regexp.MustCompile(`[A-Za-z0-9\-_]{4,}={0,2}`)
-)
+	urlSafe      bool
+	minLength    int
+	maxSize      int64
+	verbose      bool
+	showOffset   bool
+	encodingsRaw string
+	encodings    []string
+	recursive    int
+	stream       bool
+	classify     bool
+	acceptRaw    string
+	accept       []string
+	format       string
+}
+
+// jsonRecord is one match emitted by -format json/ndjson. Exactly one of
+// DecodedUTF8 or DecodedBase64 is set, depending on whether the decoded
+// payload is valid UTF-8.
+type jsonRecord struct {
+	Offset         int64   `json:"offset"`
+	Line           int     `json:"line"`
+	Encoding       string  `json:"encoding"`
+	Raw            string  `json:"raw"`
+	DecodedUTF8    string  `json:"decoded_utf8,omitempty"`
+	DecodedBase64  string  `json:"decoded_base64,omitempty"`
+	Entropy        float64 `json:"entropy"`
+	Classification string  `json:"classification"`
+	SHA256         string  `json:"sha256"`
+	ParentSHA256   string  `json:"parent_sha256,omitempty"`
+}
+
+// levelStats accumulates per-depth counters for -recursive -v reporting.
+type levelStats struct {
+	found   int
+	decoded int
+}
+
+var config Config
 
 func init() {
 	flag.BoolVar(&config.urlSafe, "url", false, "Also decode URL-safe base64 (with -_ instead of +/)")
@@ -41,25 +67,86 @@ func init() {
 	flag.Int64Var(&config.maxSize, "max-size", maxFileSize, "Maximum file size to process (bytes)")
 	flag.BoolVar(&config.verbose, "v", false, "Verbose output (show errors and statistics)")
 	flag.BoolVar(&config.showOffset, "offset", false, "Show byte offset of found strings")
+	flag.StringVar(&config.encodingsRaw, "encodings", "b64", "Comma-separated list of encodings to try: b64,b32,a85,hex,b58")
+	flag.IntVar(&config.recursive, "recursive", 0, "Recursively decode nested payloads up to N levels deep")
+	flag.BoolVar(&config.stream, "stream", false, "Remove the max-size cap (for piping unbounded stdin)")
+	flag.BoolVar(&config.classify, "classify", false, "Print entropy, classification and magic-byte info alongside decoded output")
+	flag.StringVar(&config.acceptRaw, "accept", "", "Comma-separated classifications to accept instead of the printable-text heuristic: text,structured,random")
+	flag.StringVar(&config.format, "format", "text", "Output format: text, json, ndjson")
 }
 
 func main() {
 	flag.Parse()
+	config.encodings = parseEncodings(config.encodingsRaw)
+	config.accept = parseAccept(config.acceptRaw)
+
+	switch config.format {
+	case "text", "json", "ndjson":
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown -format %q (want text, json or ndjson)\n", config.format)
+		os.Exit(1)
+	}
 
 	filename := flag.Arg(0)
 	if filename == "" {
-		fmt.Fprintln(os.Stderr, "usage: b64d [flags] <filename>")
+		fmt.Fprintln(os.Stderr, "usage: b64d [flags] <filename|->")
 		fmt.Fprintln(os.Stderr, "\nFlags:")
 		flag.PrintDefaults()
 		os.Exit(1)
 	}
 
-	if err := processFile(filename); err != nil {
+	var err error
+	if filename == "-" {
+		err = processStream(os.Stdin)
+	} else {
+		err = processFile(filename)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// parseEncodings turns a comma-separated -encodings value into an ordered,
+// deduplicated list, ignoring unknown scheme names.
+func parseEncodings(raw string) []string {
+	var enabled []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		switch name {
+		case "b64", "b32", "a85", "hex", "b58":
+			if !seen[name] {
+				enabled = append(enabled, name)
+				seen[name] = true
+			}
+		case "":
+			// ignore empty entries from trailing commas
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: unknown encoding %q ignored\n", name)
+		}
+	}
+	return enabled
+}
+
+// parseAccept turns a comma-separated -accept value into a list of
+// classification names, ignoring unknown ones.
+func parseAccept(raw string) []string {
+	var accept []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		switch name {
+		case "text", "structured", "random":
+			accept = append(accept, name)
+		case "":
+			// ignore empty entries from trailing commas, or an unset flag
+		default:
+			fmt.Fprintf(os.Stderr, "Warning: unknown classification %q ignored\n", name)
+		}
+	}
+	return accept
+}
+
 func processFile(filename string) error {
 	// Check file size first
 	info, err := os.Stat(filename)
@@ -67,7 +154,7 @@ func processFile(filename string) error {
 		return fmt.Errorf("cannot stat file: %w", err)
 	}
 
-	if info.Size() > config.maxSize {
+	if !config.stream && info.Size() > config.maxSize {
 		return fmt.Errorf("file too large (%d bytes, max %d)", info.Size(), config.maxSize)
 	}
 
@@ -80,159 +167,241 @@ func processFile(filename string) error {
 	return findAndDecode(f)
 }
 
+// processStream decodes from a reader with no known size up front (stdin,
+// pipes). Unless -stream is set, reads are capped at max-size so a runaway
+// pipe can't exhaust memory.
+func processStream(r io.Reader) error {
+	if !config.stream {
+		r = &capReader{r: r, limit: config.maxSize}
+	}
+	return findAndDecode(r)
+}
+
+// capReader errors out once more than limit bytes have been read, used to
+// enforce max-size on readers (like stdin) that can't be os.Stat'd up front.
+type capReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	if c.read >= c.limit {
+		return 0, fmt.Errorf("input exceeds max size (%d bytes); use -stream to remove the cap", c.limit)
+	}
+	if remaining := c.limit - c.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+	return n, err
+}
+
+// findAndDecode drives a b64d.Scanner over r, printing each match it yields
+// and, when -recursive is set, re-scanning decoded payloads for nested
+// matches.
 func findAndDecode(r io.Reader) error {
-	scanner := bufio.NewScanner(r)
-	scanner.Buffer(make([]byte, chunkSize), chunkSize)
-	
-	var lineNum int
-	var totalFound, totalDecoded int
-	patterns := []string{}
-
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
-		
-		// Find potential base64 strings
-		matches := findBase64Patterns(line)
-		
-		for _, match := range matches {
-			if totalFound >= maxMatches {
-				if config.verbose {
-					fmt.Fprintf(os.Stderr, "Warning: reached maximum match limit (%d)\n", maxMatches)
-				}
-				return nil
-			}
-			totalFound++
+	scanner := b64d.NewScanner(r, scanOptions())
 
-			decoded, err := decodeBase64(match)
-			if err != nil {
-				if config.verbose {
-					fmt.Fprintf(os.Stderr, "Line %d: decode error for '%s': %v\n", lineNum, truncate(match, 20), err)
-				}
-				continue
-			}
+	var totalFound int
+	byDepth := map[int]*levelStats{}
+	var records []jsonRecord
 
-			if !isValidOutput(decoded) {
-				continue
-			}
+	for {
+		m, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
 
-			totalDecoded++
-			if config.showOffset {
-				fmt.Printf("Line %d: %s\n", lineNum, decoded)
-			} else {
-				fmt.Println(decoded)
-			}
-			
-			if config.verbose {
-				patterns = append(patterns, match)
-			}
+		totalFound++
+		depthStat(byDepth, 0).found++
+		depthStat(byDepth, 0).decoded++
+
+		label := matchLabel(m.Offset)
+		hash := emitMatch(&records, label, m, config.showOffset, "")
+
+		if config.recursive > 0 {
+			seen := map[string]bool{hash: true}
+			recurseDecode(&records, label, m.Decoded, 1, seen, byDepth, hash)
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("read error: %w", err)
-	}
+	flushRecords(records)
 
 	if config.verbose {
 		fmt.Fprintf(os.Stderr, "\nStatistics:\n")
-		fmt.Fprintf(os.Stderr, "  Total patterns found: %d\n", totalFound)
-		fmt.Fprintf(os.Stderr, "  Successfully decoded: %d\n", totalDecoded)
+		fmt.Fprintf(os.Stderr, "  Total matches decoded: %d\n", totalFound)
+		if config.recursive > 0 {
+			fmt.Fprintf(os.Stderr, "  Per-depth breakdown:\n")
+			for depth := 0; depth <= config.recursive; depth++ {
+				if s, ok := byDepth[depth]; ok {
+					fmt.Fprintf(os.Stderr, "    Depth %d: found %d, decoded %d\n", depth, s.found, s.decoded)
+				}
+			}
+		}
 	}
 
 	return nil
 }
 
-func findBase64Patterns(content string) []string {
-	var matches []string
-	seen := make(map[string]bool)
+func scanOptions() b64d.Options {
+	return b64d.Options{
+		URLSafe:   config.urlSafe,
+		MinLength: config.minLength,
+		Encodings: config.encodings,
+		Accept:    config.accept,
+	}
+}
 
-	// Find standard base64
-	for _, match := range stdB64Pattern.FindAllString(content, -1) {
-		if len(match) >= config.minLength && isValidBase64Length(match) {
-			if !seen[match] {
-				matches = append(matches, match)
-				seen[match] = true
-			}
-		}
+// matchLabel formats a match's absolute byte offset for display.
+func matchLabel(offset int64) string {
+	return fmt.Sprintf("Offset %d", offset)
+}
+
+func depthStat(byDepth map[int]*levelStats, depth int) *levelStats {
+	s, ok := byDepth[depth]
+	if !ok {
+		s = &levelStats{}
+		byDepth[depth] = s
 	}
+	return s
+}
 
-	// Find URL-safe base64 if enabled
-	if config.urlSafe {
-		for _, match := range urlB64Pattern.FindAllString(content, -1) {
-			if len(match) >= config.minLength && isValidBase64Length(match) {
-				if !seen[match] {
-					matches = append(matches, match)
-					seen[match] = true
-				}
-			}
+// recurseDecode re-scans a decoded payload for further encoded matches, up
+// to config.recursive levels deep. seen tracks SHA-256 hashes of decoded
+// payloads already emitted in this chain so self-referential input can't
+// recurse forever.
+func recurseDecode(records *[]jsonRecord, parentLabel string, content []byte, depth int, seen map[string]bool, byDepth map[int]*levelStats, parentSHA256 string) {
+	if depth > config.recursive {
+		return
+	}
+	if int64(len(content)) > config.maxSize {
+		if config.verbose {
+			fmt.Fprintf(os.Stderr, "%s: recursion stopped, decoded output exceeds max-size\n", parentLabel)
 		}
+		return
 	}
 
-	return matches
+	scanner := b64d.NewScanner(bytes.NewReader(content), scanOptions())
+	label := fmt.Sprintf("%s > L%d", parentLabel, depth)
+
+	for {
+		m, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		depthStat(byDepth, depth).found++
+
+		hash := sha256Hex(m.Decoded)
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		depthStat(byDepth, depth).decoded++
+		emitMatch(records, label, m, true, parentSHA256)
+		recurseDecode(records, label, m.Decoded, depth+1, seen, byDepth, hash)
+	}
 }
 
-func isValidBase64Length(s string) bool {
-	// Remove padding
-	s = strings.TrimRight(s, "=")
-	// Valid base64 should be 4n or 4n+2 or 4n+3 in length (after removing padding)
-	rem := len(s) % 4
-	return rem == 0 || rem == 2 || rem == 3
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
 }
 
-func decodeBase64(s string) (string, error) {
-	// Try standard base64 first
-	decoded, err := base64.StdEncoding.DecodeString(s)
-	if err == nil {
-		return string(decoded), nil
+// emitMatch prints or records one match according to config.format and
+// returns the hex SHA-256 of its decoded payload, so callers can thread it
+// through as parent_sha256 for recursive matches.
+func emitMatch(records *[]jsonRecord, label string, m b64d.Match, showLabel bool, parentSHA256 string) string {
+	hash := sha256Hex(m.Decoded)
+
+	switch config.format {
+	case "json":
+		*records = append(*records, newJSONRecord(m, hash, parentSHA256))
+	case "ndjson":
+		printRecord(newJSONRecord(m, hash, parentSHA256))
+	default:
+		printDecoded(label, m, showLabel)
 	}
 
-	// Try URL-safe if enabled and standard failed
-	if config.urlSafe {
-		decoded, err = base64.URLEncoding.DecodeString(s)
-		if err == nil {
-			return string(decoded), nil
-		}
+	return hash
+}
 
-		// Try raw URL encoding (no padding)
-		decoded, err = base64.RawURLEncoding.DecodeString(s)
-		if err == nil {
-			return string(decoded), nil
-		}
+// newJSONRecord builds the -format json/ndjson record for m, encoding its
+// decoded payload as decoded_base64 instead of decoded_utf8 when it isn't
+// valid UTF-8.
+func newJSONRecord(m b64d.Match, sha256Hash, parentSHA256 string) jsonRecord {
+	rec := jsonRecord{
+		Offset:         m.Offset,
+		Line:           m.Line,
+		Encoding:       m.Encoding,
+		Raw:            m.Raw,
+		Entropy:        m.Entropy,
+		Classification: string(m.Classification),
+		SHA256:         sha256Hash,
+		ParentSHA256:   parentSHA256,
+	}
+	if utf8.Valid(m.Decoded) {
+		rec.DecodedUTF8 = string(m.Decoded)
+	} else {
+		rec.DecodedBase64 = base64.StdEncoding.EncodeToString(m.Decoded)
 	}
+	return rec
+}
 
-	// Try raw standard encoding (no padding)
-	decoded, err = base64.RawStdEncoding.DecodeString(s)
-	if err == nil {
-		return string(decoded), nil
+func printRecord(rec jsonRecord) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling record: %v\n", err)
+		return
 	}
+	fmt.Println(string(b))
+}
 
-	return "", fmt.Errorf("invalid base64")
+// flushRecords prints the accumulated records as a single JSON array; it is
+// a no-op unless config.format is "json" (ndjson prints as it goes).
+func flushRecords(records []jsonRecord) {
+	if config.format != "json" {
+		return
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error marshaling records: %v\n", err)
+		return
+	}
+	fmt.Println(string(b))
 }
 
-func isValidOutput(s string) bool {
-	if s == "" {
-		return false
+// printDecoded prints one decoded match. showLabel controls whether the
+// offset/depth label prefix ("Offset 12 > L1 > L2: ") is shown; recursive
+// levels always show it so the tree structure stays readable. When
+// -classify is set, entropy/classification/magic info is appended.
+func printDecoded(label string, m b64d.Match, showLabel bool) {
+	prefix := ""
+	if config.verbose {
+		prefix = fmt.Sprintf("[%s] ", m.Encoding)
 	}
 
-	// Check if string contains mostly printable ASCII
-	printableCount := 0
-	for _, r := range s {
-		// Allow printable ASCII and common whitespace
-		if unicode.IsPrint(r) || r == '\n' || r == '\r' || r == '\t' {
-			printableCount++
-		} else if !unicode.IsSpace(r) {
-			// Non-printable, non-whitespace character
-			return false
+	suffix := ""
+	if config.classify {
+		magic := m.Magic
+		if magic == "" {
+			magic = "none"
 		}
+		suffix = fmt.Sprintf(" (entropy=%.2f, class=%s, magic=%s)", m.Entropy, m.Classification, magic)
 	}
 
-	// Require at least 75% printable characters
-	return float64(printableCount)/float64(len(s)) >= 0.75
-}
-
-func truncate(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+	decoded := string(m.Decoded)
+	if showLabel {
+		fmt.Printf("%s%s: %s%s\n", prefix, label, decoded, suffix)
+	} else {
+		fmt.Printf("%s%s%s\n", prefix, decoded, suffix)
 	}
-	return s[:maxLen] + "..."
-}
\ No newline at end of file
+}
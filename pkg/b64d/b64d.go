@@ -0,0 +1,616 @@
+// Package b64d finds and decodes base64 and related encodings embedded in
+// arbitrary byte streams. It is the library underneath the b64d CLI; see
+// cmd usage in the repository root for a thin consumer example.
+package b64d
+
+import (
+	"bytes"
+	"encoding/ascii85"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultMinLength is the minimum candidate length tried when
+	// Options.MinLength is left at zero.
+	DefaultMinLength = 4
+	// DefaultMaxMatches caps how many candidates a Scanner will return
+	// before reporting io.EOF, used when Options.MaxMatches is zero.
+	DefaultMaxMatches = 10000
+
+	chunkSize = 64 * 1024
+	// windowOverlap must be at least as large as the longest candidate
+	// string expected. Any match still extending into the last
+	// windowOverlap bytes of the window is held back rather than queued,
+	// so it gets rematched in full once more data has arrived instead of
+	// being cut and reported twice at the boundary.
+	windowOverlap = 8 * 1024
+)
+
+// encodingPriority controls the order schemes are tried in when several are
+// enabled, so that a string matching more than one alphabet (e.g. base64
+// and base58) is only ever reported once, under the scheme most likely to
+// be correct.
+var encodingPriority = []string{"b64", "b32", "a85", "hex", "b58"}
+
+var (
+	stdB64Pattern   = regexp.MustCompile(`[A-Za-z0-9+/]{4,}={0,2}`)
+	urlB64Pattern   = regexp.MustCompile(`[A-Za-z0-9\-_]{4,}={0,2}`)
+	b32Pattern      = regexp.MustCompile(`[A-Z2-7]{8,}={0,6}`)
+	a85DelimPattern = regexp.MustCompile(`<~[!-u]{5,}~>`)
+	a85BarePattern  = regexp.MustCompile(`[!-u]{5,}`)
+	hexPattern      = regexp.MustCompile(`[0-9a-fA-F]{8,}`)
+	b58Pattern      = regexp.MustCompile(`[1-9A-HJ-NP-Za-km-z]{4,}`)
+
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+)
+
+// Options configures a Scanner. The zero value is valid and scans for
+// standard base64 only, with the default printable-output heuristic.
+type Options struct {
+	// URLSafe also tries the URL-safe base64 alphabet and raw (unpadded)
+	// variants when decoding "b64" candidates.
+	URLSafe bool
+	// MinLength is the minimum candidate length to attempt. Defaults to
+	// DefaultMinLength.
+	MinLength int
+	// Encodings lists which schemes to search for: any of "b64", "b32",
+	// "a85", "hex", "b58". Defaults to []string{"b64"}.
+	Encodings []string
+	// MaxMatches caps how many total candidates Next will consider
+	// before returning io.EOF. Defaults to DefaultMaxMatches.
+	MaxMatches int
+	// ValidateFn decides whether decoded output is worth reporting.
+	// Defaults to IsPrintableText, which requires >=75% printable ASCII.
+	// If left nil and Accept is non-empty, a classification-based
+	// validator built from Accept is used instead.
+	ValidateFn func([]byte) bool
+	// Accept, when set and ValidateFn is nil, accepts decoded output
+	// whose Classify result is one of these classes ("text", "structured",
+	// "random") instead of applying the printable-text heuristic.
+	Accept []string
+}
+
+// Match is one decoded candidate found by a Scanner.
+type Match struct {
+	// Offset is the candidate's absolute byte offset in the scanned stream.
+	Offset int64
+	// Raw is the matched (still-encoded) substring.
+	Raw string
+	// Decoded is the successfully decoded payload.
+	Decoded []byte
+	// Encoding is the scheme that produced Decoded: "b64", "b32", "a85",
+	// "hex" or "b58".
+	Encoding string
+	// Entropy is the Shannon entropy (bits/byte) of Decoded.
+	Entropy float64
+	// Classification is Classify(Decoded).
+	Classification Classification
+	// Magic is MagicType(Decoded), or "" if no signature matched.
+	Magic string
+	// Line is the 1-based line number containing Offset.
+	Line int
+}
+
+type candidate struct {
+	raw      string
+	start    int
+	line     int
+	encoding string
+}
+
+// Scanner scans an io.Reader for encoded candidates and decodes them on
+// demand via Next. It reads in a sliding window so candidates straddling
+// two underlying reads are still matched whole, and works equally well on
+// binary input with no line structure.
+type Scanner struct {
+	r         io.Reader
+	opts      Options
+	encodings map[string]bool
+
+	window       []byte
+	streamOffset int64
+	lineBase     int
+	readBuf      []byte
+	done         bool
+
+	// queued tracks matches already queued whose bytes are still sitting
+	// in the window because a nearby held-back match (see fill) delayed
+	// discarding them, keyed by absolute start offset and encoding so they
+	// aren't queued a second time when that region is rescanned. Entries
+	// are pruned once the window advances past them.
+	queued map[string]int64
+
+	pending    []candidate
+	totalFound int
+}
+
+// NewScanner creates a Scanner over r using opts.
+func NewScanner(r io.Reader, opts Options) *Scanner {
+	if opts.MinLength == 0 {
+		opts.MinLength = DefaultMinLength
+	}
+	if opts.MaxMatches == 0 {
+		opts.MaxMatches = DefaultMaxMatches
+	}
+	if opts.ValidateFn == nil {
+		if len(opts.Accept) > 0 {
+			opts.ValidateFn = acceptClassifications(opts.Accept)
+		} else {
+			opts.ValidateFn = IsPrintableText
+		}
+	}
+
+	encodings := make(map[string]bool)
+	if len(opts.Encodings) == 0 {
+		encodings["b64"] = true
+	} else {
+		for _, e := range opts.Encodings {
+			encodings[e] = true
+		}
+	}
+
+	return &Scanner{
+		r:         r,
+		opts:      opts,
+		encodings: encodings,
+		readBuf:   make([]byte, chunkSize),
+	}
+}
+
+// acceptClassifications builds a ValidateFn that accepts decoded output
+// whose Classify result is in the given (case-insensitive) class names.
+func acceptClassifications(classes []string) func([]byte) bool {
+	accept := make(map[Classification]bool, len(classes))
+	for _, c := range classes {
+		accept[Classification(strings.ToLower(c))] = true
+	}
+	return func(b []byte) bool {
+		return accept[Classify(b)]
+	}
+}
+
+// Next returns the next decodable match, or io.EOF once the stream is
+// exhausted or MaxMatches has been reached. Candidates that fail to decode
+// or fail ValidateFn are skipped silently; callers that need to see them
+// should scan with a permissive ValidateFn.
+func (s *Scanner) Next() (Match, error) {
+	for {
+		for len(s.pending) > 0 {
+			c := s.pending[0]
+			s.pending = s.pending[1:]
+
+			if s.totalFound >= s.opts.MaxMatches {
+				return Match{}, io.EOF
+			}
+			s.totalFound++
+
+			decoded, err := decodeByEncoding(c.raw, c.encoding, s.opts.URLSafe)
+			if err != nil {
+				continue
+			}
+			if !s.opts.ValidateFn(decoded) {
+				continue
+			}
+
+			return Match{
+				Offset:         int64(c.start),
+				Raw:            c.raw,
+				Decoded:        decoded,
+				Encoding:       c.encoding,
+				Entropy:        Entropy(decoded),
+				Classification: Classify(decoded),
+				Magic:          MagicType(decoded),
+				Line:           c.line,
+			}, nil
+		}
+
+		if s.done {
+			return Match{}, io.EOF
+		}
+
+		if err := s.fill(); err != nil {
+			return Match{}, err
+		}
+	}
+}
+
+// fill reads the next block of input and scans the whole buffered window
+// (not just the newly read part), so a match that was already partly
+// visible in an earlier round is reconsidered together with the data that
+// now follows it rather than being matched short. Matches that still reach
+// into the trailing windowOverlap bytes are held back instead of queued,
+// since more data may yet extend them; they are left in the window and
+// rematched in full on a later call once enough data has arrived.
+func (s *Scanner) fill() error {
+	n, err := s.r.Read(s.readBuf)
+	if n > 0 {
+		s.window = append(s.window, s.readBuf[:n]...)
+	}
+	if err != nil {
+		if err != io.EOF {
+			return fmt.Errorf("read error: %w", err)
+		}
+		s.done = true
+	}
+
+	threshold := len(s.window)
+	if !s.done {
+		if threshold <= windowOverlap {
+			return nil // not enough buffered yet to safely scan
+		}
+		threshold -= windowOverlap
+	}
+
+	// Bytes below safeDiscard can be dropped from the window once this
+	// round is done: every match starting there either has already been
+	// queued, or ended up held back and must stay in the window so it is
+	// rescanned whole next time.
+	safeDiscard := threshold
+	var resolved []candidate
+	for _, c := range findAllPatterns(s.window, s.encodings, s.opts.MinLength, s.opts.URLSafe) {
+		end := c.start + len(c.raw)
+		if !s.done && end > threshold {
+			if c.start < safeDiscard {
+				safeDiscard = c.start
+			}
+			continue
+		}
+		resolved = append(resolved, c)
+	}
+
+	for _, c := range resolved {
+		end := c.start + len(c.raw)
+		absStart := s.streamOffset + int64(c.start)
+
+		key := queuedKey(absStart, c.encoding)
+		if _, ok := s.queued[key]; ok {
+			continue // already queued on an earlier scan of this still-retained region
+		}
+
+		c.line = s.lineBase + 1 + bytes.Count(s.window[:c.start], []byte("\n"))
+		c.start = int(absStart)
+		s.pending = append(s.pending, c)
+
+		if end > safeDiscard { // bytes outlive this round's discard; remember it
+			if s.queued == nil {
+				s.queued = make(map[string]int64)
+			}
+			s.queued[key] = absStart
+		}
+	}
+
+	discarded := s.window[:safeDiscard]
+	s.lineBase += bytes.Count(discarded, []byte("\n"))
+	s.streamOffset += int64(safeDiscard)
+	s.window = s.window[safeDiscard:]
+
+	for key, absStart := range s.queued {
+		if absStart < s.streamOffset {
+			delete(s.queued, key)
+		}
+	}
+
+	return nil
+}
+
+// queuedKey identifies an already-queued match by its absolute offset and
+// encoding, so a region rescanned while a nearby match is held back doesn't
+// get requeued.
+func queuedKey(absStart int64, encoding string) string {
+	return strconv.FormatInt(absStart, 10) + "|" + encoding
+}
+
+// findAllPatterns runs every enabled encoding's matcher over content, in
+// encodingPriority order, so a string that satisfies more than one alphabet
+// is only reported once under its highest-priority scheme.
+func findAllPatterns(content []byte, encodings map[string]bool, minLength int, urlSafe bool) []candidate {
+	var matches []candidate
+	seen := make(map[string]bool)
+
+	for _, enc := range encodingPriority {
+		if !encodings[enc] {
+			continue
+		}
+		for _, m := range findPatternsForEncoding(content, enc, minLength, urlSafe) {
+			if seen[m.raw] {
+				continue
+			}
+			seen[m.raw] = true
+			matches = append(matches, m)
+		}
+	}
+
+	return matches
+}
+
+// excludeCovered drops matches whose byte range overlaps any of the given
+// ranges, so a per-line candidate already folded into an assembled wrapped
+// block (see findWrappedBase64Blocks) isn't also reported as its own
+// fragment.
+func excludeCovered(matches []candidate, covered [][2]int) []candidate {
+	if len(covered) == 0 {
+		return matches
+	}
+	out := matches[:0]
+	for _, m := range matches {
+		end := m.start + len(m.raw)
+		overlaps := false
+		for _, c := range covered {
+			if m.start < c[1] && c[0] < end {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func findPatternsForEncoding(content []byte, encoding string, minLength int, urlSafe bool) []candidate {
+	switch encoding {
+	case "b64":
+		wrapped, covered := findWrappedBase64Blocks(content, minLength)
+		matches := excludeCovered(findBase64Patterns(content, minLength, urlSafe), covered)
+		return append(matches, wrapped...)
+	case "b32":
+		return findIndexMatches(content, b32Pattern.FindAllIndex(content, -1), "b32", minLength, isValidBase32Length)
+	case "a85":
+		return findAscii85Patterns(content, minLength)
+	case "hex":
+		return findIndexMatches(content, hexPattern.FindAllIndex(content, -1), "hex", minLength, isValidHexLength)
+	case "b58":
+		return findIndexMatches(content, b58Pattern.FindAllIndex(content, -1), "b58", minLength, func(string) bool { return true })
+	default:
+		return nil
+	}
+}
+
+func findIndexMatches(content []byte, idx [][]int, encoding string, minLength int, valid func(string) bool) []candidate {
+	var matches []candidate
+	seen := make(map[string]bool)
+	for _, loc := range idx {
+		raw := string(content[loc[0]:loc[1]])
+		if len(raw) >= minLength && valid(raw) && !seen[raw] {
+			matches = append(matches, candidate{raw: raw, start: loc[0], encoding: encoding})
+			seen[raw] = true
+		}
+	}
+	return matches
+}
+
+// findAscii85Patterns finds both delimited (`<~...~>`) and bare ascii85 runs.
+// A delimited block's interior always also satisfies the bare pattern, so
+// bare matches falling inside an already-reported delimited block's range
+// are dropped rather than reported a second time.
+func findAscii85Patterns(content []byte, minLength int) []candidate {
+	var matches []candidate
+	seen := make(map[string]bool)
+	var covered [][2]int
+
+	for _, loc := range a85DelimPattern.FindAllIndex(content, -1) {
+		raw := string(content[loc[0]:loc[1]])
+		if !seen[raw] {
+			matches = append(matches, candidate{raw: raw, start: loc[0], encoding: "a85"})
+			seen[raw] = true
+		}
+		covered = append(covered, [2]int{loc[0], loc[1]})
+	}
+
+	var bare []candidate
+	for _, loc := range a85BarePattern.FindAllIndex(content, -1) {
+		raw := string(content[loc[0]:loc[1]])
+		if len(raw) >= minLength && isValidAscii85Length(raw) && !seen[raw] {
+			bare = append(bare, candidate{raw: raw, start: loc[0], encoding: "a85"})
+			seen[raw] = true
+		}
+	}
+
+	return append(matches, excludeCovered(bare, covered)...)
+}
+
+func findBase64Patterns(content []byte, minLength int, urlSafe bool) []candidate {
+	var matches []candidate
+	seen := make(map[string]bool)
+
+	for _, loc := range stdB64Pattern.FindAllIndex(content, -1) {
+		raw := string(content[loc[0]:loc[1]])
+		if len(raw) >= minLength && isValidBase64Length(raw) {
+			if !seen[raw] {
+				matches = append(matches, candidate{raw: raw, start: loc[0], encoding: "b64"})
+				seen[raw] = true
+			}
+		}
+	}
+
+	if urlSafe {
+		for _, loc := range urlB64Pattern.FindAllIndex(content, -1) {
+			raw := string(content[loc[0]:loc[1]])
+			if len(raw) >= minLength && isValidBase64Length(raw) {
+				if !seen[raw] {
+					matches = append(matches, candidate{raw: raw, start: loc[0], encoding: "b64"})
+					seen[raw] = true
+				}
+			}
+		}
+	}
+
+	return matches
+}
+
+func isValidBase64Length(s string) bool {
+	s = strings.TrimRight(s, "=")
+	rem := len(s) % 4
+	return rem == 0 || rem == 2 || rem == 3
+}
+
+// isValidBase32Length checks that, once padding is stripped, the string is
+// a length the RFC 4648 base32 alphabet can actually produce from whole
+// bytes (groups of 8 characters encode groups of 5 bytes).
+func isValidBase32Length(s string) bool {
+	s = strings.TrimRight(s, "=")
+	rem := len(s) % 8
+	switch rem {
+	case 0, 2, 4, 5, 7:
+		return true
+	default:
+		return false
+	}
+}
+
+// isValidHexLength requires an even number of digits, since hex encodes
+// whole bytes as two-digit groups.
+func isValidHexLength(s string) bool {
+	return len(s)%2 == 0
+}
+
+// isValidAscii85Length checks that the bare (undelimited) candidate is a
+// length ascii85 can decode: whole groups of 5 characters, with a final
+// partial group of 2-5.
+func isValidAscii85Length(s string) bool {
+	rem := len(s) % 5
+	return rem == 0 || rem >= 2
+}
+
+func decodeByEncoding(s, encoding string, urlSafe bool) ([]byte, error) {
+	switch encoding {
+	case "b64":
+		return decodeBase64(s, urlSafe)
+	case "b32":
+		return decodeBase32(s)
+	case "a85":
+		return decodeAscii85(s)
+	case "hex":
+		return decodeHex(s)
+	case "b58":
+		return decodeBase58(s)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+}
+
+func decodeBase64(s string, urlSafe bool) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(s)
+	if err == nil {
+		return decoded, nil
+	}
+
+	if urlSafe {
+		decoded, err = base64.URLEncoding.DecodeString(s)
+		if err == nil {
+			return decoded, nil
+		}
+
+		decoded, err = base64.RawURLEncoding.DecodeString(s)
+		if err == nil {
+			return decoded, nil
+		}
+	}
+
+	decoded, err = base64.RawStdEncoding.DecodeString(s)
+	if err == nil {
+		return decoded, nil
+	}
+
+	return nil, fmt.Errorf("invalid base64")
+}
+
+func decodeBase32(s string) ([]byte, error) {
+	decoded, err := base32.StdEncoding.DecodeString(s)
+	if err == nil {
+		return decoded, nil
+	}
+
+	decoded, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err == nil {
+		return decoded, nil
+	}
+
+	return nil, fmt.Errorf("invalid base32")
+}
+
+func decodeHex(s string) ([]byte, error) {
+	decoded, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %w", err)
+	}
+	return decoded, nil
+}
+
+func decodeAscii85(s string) ([]byte, error) {
+	s = strings.TrimPrefix(s, "<~")
+	s = strings.TrimSuffix(s, "~>")
+
+	dst := make([]byte, len(s))
+	ndst, _, err := ascii85.Decode(dst, []byte(s), true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ascii85: %w", err)
+	}
+	return dst[:ndst], nil
+}
+
+// decodeBase58 implements the Bitcoin base58 alphabet; the standard library
+// has no built-in support for it.
+func decodeBase58(s string) ([]byte, error) {
+	result := big.NewInt(0)
+	base := big.NewInt(58)
+
+	for _, c := range s {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		result.Mul(result, base)
+		result.Add(result, big.NewInt(int64(idx)))
+	}
+
+	decoded := result.Bytes()
+
+	leadingZeros := 0
+	for _, c := range s {
+		if c != '1' {
+			break
+		}
+		leadingZeros++
+	}
+
+	out := make([]byte, leadingZeros+len(decoded))
+	copy(out[leadingZeros:], decoded)
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("invalid base58")
+	}
+
+	return out, nil
+}
+
+// IsPrintableText is the default Options.ValidateFn: it requires at least
+// 75% printable ASCII (plus common whitespace), which discards noise while
+// keeping plain-text payloads.
+func IsPrintableText(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	printableCount := 0
+	s := string(b)
+	for _, r := range s {
+		if unicode.IsPrint(r) || r == '\n' || r == '\r' || r == '\t' {
+			printableCount++
+		} else if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return float64(printableCount)/float64(len(b)) >= 0.75
+}
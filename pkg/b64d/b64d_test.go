@@ -0,0 +1,283 @@
+package b64d
+
+import (
+	"encoding/ascii85"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func base64Of(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func TestScannerNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    Options
+		want    []string // expected Match.Decoded values, in order
+		wantEnc []string
+	}{
+		{
+			name:    "standard base64",
+			input:   "1: SGVsbG8sIFdvcmxkIQ== 2:",
+			opts:    Options{},
+			want:    []string{"Hello, World!"},
+			wantEnc: []string{"b64"},
+		},
+		{
+			name:    "ignores plain text below min length",
+			input:   "abc",
+			opts:    Options{},
+			want:    nil,
+			wantEnc: nil,
+		},
+		{
+			name:    "url-safe base64",
+			input:   "token=: SGk-",
+			opts:    Options{URLSafe: true},
+			want:    []string{"Hi>"},
+			wantEnc: []string{"b64"},
+		},
+		{
+			name:    "hex",
+			input:   "raw: 68656c6c6f",
+			opts:    Options{Encodings: []string{"hex"}},
+			want:    []string{"hello"},
+			wantEnc: []string{"hex"},
+		},
+		{
+			name:    "base32",
+			input:   "JBSWY3DPEBLW64TMMQ======",
+			opts:    Options{Encodings: []string{"b32"}},
+			want:    []string{"Hello World"},
+			wantEnc: []string{"b32"},
+		},
+		{
+			name:    "multiple encodings, priority order",
+			input:   "1: JBSWY3DPEBLW64TMMQ====== 2: 68656c6c6f",
+			opts:    Options{Encodings: []string{"b32", "hex"}},
+			want:    []string{"Hello World", "hello"},
+			wantEnc: []string{"b32", "hex"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(strings.NewReader(tt.input), tt.opts)
+
+			var got []string
+			var gotEnc []string
+			for {
+				m, err := s.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error: %v", err)
+				}
+				got = append(got, string(m.Decoded))
+				gotEnc = append(gotEnc, m.Encoding)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d matches %v, want %d %v", len(got), got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("match %d: got %q, want %q", i, got[i], tt.want[i])
+				}
+				if gotEnc[i] != tt.wantEnc[i] {
+					t.Errorf("match %d encoding: got %q, want %q", i, gotEnc[i], tt.wantEnc[i])
+				}
+			}
+		})
+	}
+}
+
+func TestScannerLineNumbers(t *testing.T) {
+	input := "1:\n2: " + base64Of("hello") + "\n3:\n4: " + base64Of("world")
+	s := NewScanner(strings.NewReader(input), Options{})
+
+	var lines []int
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		lines = append(lines, m.Line)
+	}
+
+	want := []int{2, 4}
+	if len(lines) != len(want) {
+		t.Fatalf("got lines %v, want %v", lines, want)
+	}
+	for i := range lines {
+		if lines[i] != want[i] {
+			t.Errorf("match %d: got line %d, want %d", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestScannerCustomValidateFn(t *testing.T) {
+	// Base64 of "\x00\x01\x02" -- fails the default printable heuristic but
+	// should come through with a permissive ValidateFn.
+	input := "AAEC"
+	s := NewScanner(strings.NewReader(input), Options{
+		MinLength:  4,
+		ValidateFn: func([]byte) bool { return true },
+	})
+
+	m, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next() error: %v", err)
+	}
+	want := []byte{0x00, 0x01, 0x02}
+	if string(m.Decoded) != string(want) {
+		t.Fatalf("got %v, want %v", m.Decoded, want)
+	}
+}
+
+func TestScannerMaxMatches(t *testing.T) {
+	words := []string{
+		base64Of("hello one"), base64Of("hello two"), base64Of("hello three"),
+		base64Of("hello four"), base64Of("hello five"),
+	}
+	input := strings.Join(words, " : ")
+	s := NewScanner(strings.NewReader(input), Options{MaxMatches: 2})
+
+	count := 0
+	for {
+		_, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		count++
+	}
+
+	if count != 2 {
+		t.Fatalf("got %d matches, want 2", count)
+	}
+}
+
+// TestScannerCandidateSpanningWindowBoundary covers a candidate long enough
+// to straddle the sliding window's internal read boundary (chunkSize minus
+// windowOverlap bytes into the stream): it must come back as one whole
+// match, not cut in two at the boundary.
+func TestScannerCandidateSpanningWindowBoundary(t *testing.T) {
+	payload := strings.Repeat("the quick brown fox jumps over the lazy dog. ", 80)
+	encoded := base64Of(payload)
+
+	filler := strings.Repeat(". ", 30000)
+	input := filler + encoded + filler
+
+	s := NewScanner(strings.NewReader(input), Options{})
+
+	whole := 0
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		if m.Raw == encoded {
+			whole++
+		} else if strings.Contains(encoded, m.Raw) {
+			t.Fatalf("candidate was split at the window boundary: got fragment %q", m.Raw)
+		}
+	}
+	if whole != 1 {
+		t.Fatalf("got %d whole matches of the boundary-straddling candidate, want 1", whole)
+	}
+}
+
+// TestScannerCandidateStartingBeforeWindowThreshold covers a candidate that
+// *begins* before the scan threshold (chunkSize-windowOverlap bytes into the
+// stream) but extends past it, so it is held back on the first fill() call.
+// It must still be queued once it is rescanned whole, not dropped because
+// its start offset now looks like it falls in already-resolved territory.
+func TestScannerCandidateStartingBeforeWindowThreshold(t *testing.T) {
+	payload := strings.Repeat("the quick brown fox jumps over the lazy dog. ", 200)
+	encoded := base64Of(payload)[:5000]
+
+	before := strings.Repeat(". ", 27500) // ~55000 bytes, just short of the ~57344-byte threshold
+	after := strings.Repeat(". ", 10000)
+	input := before + encoded + after
+
+	s := NewScanner(strings.NewReader(input), Options{})
+
+	whole := 0
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		if m.Raw == encoded {
+			whole++
+		} else if strings.Contains(encoded, m.Raw) {
+			t.Fatalf("candidate was split at the window boundary: got fragment %q", m.Raw)
+		}
+	}
+	if whole != 1 {
+		t.Fatalf("got %d whole matches of the boundary-straddling candidate, want 1", whole)
+	}
+}
+
+// TestScannerAscii85DelimitedNotDoubleReported guards against a delimited
+// <~...~> block also being reported as its bare interior, which would
+// decode and print the same payload twice.
+func TestScannerAscii85DelimitedNotDoubleReported(t *testing.T) {
+	payload := "Hello World"
+	dst := make([]byte, ascii85.MaxEncodedLen(len(payload)))
+	n := ascii85.Encode(dst, []byte(payload))
+	input := "data: <~" + string(dst[:n]) + "~> end"
+
+	s := NewScanner(strings.NewReader(input), Options{Encodings: []string{"a85"}})
+
+	count := 0
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		if string(m.Decoded) == payload {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("got %d matches decoding to %q, want exactly 1", count, payload)
+	}
+}
+
+func ExampleScanner() {
+	s := NewScanner(strings.NewReader("found: SGVsbG8sIFdvcmxkIQ=="), Options{})
+
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(string(m.Decoded))
+	}
+	// Output: Hello, World!
+}
@@ -0,0 +1,88 @@
+package b64d
+
+import (
+	"bytes"
+	"math"
+)
+
+// Classification buckets decoded output by how random it looks, as a
+// complement to (not a replacement for) the plain-printable-text check:
+// compressed/encrypted/serialized payloads are "interesting" even though
+// they aren't printable ASCII.
+type Classification string
+
+const (
+	ClassText       Classification = "text"
+	ClassStructured Classification = "structured"
+	ClassRandom     Classification = "random"
+)
+
+var magicSignatures = []struct {
+	magic []byte
+	name  string
+}{
+	{[]byte{0x1f, 0x8b}, "gzip"},
+	{[]byte{0x50, 0x4b, 0x03, 0x04}, "zip"},
+	{[]byte{0x7f, 0x45, 0x4c, 0x46}, "elf"},
+	{[]byte{0x4d, 0x5a}, "pe"},
+}
+
+// Entropy computes the Shannon entropy (in bits per byte) of b's byte
+// frequency distribution: H = -sum(p_i * log2(p_i)).
+func Entropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+
+	var freq [256]int
+	for _, c := range b {
+		freq[c]++
+	}
+
+	n := float64(len(b))
+	var h float64
+	for _, count := range freq {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / n
+		h -= p * math.Log2(p)
+	}
+
+	return h
+}
+
+// Classify buckets b as "text" (low entropy, mostly printable), "structured"
+// (medium entropy - likely compressed or serialized data) or "random"
+// (high entropy - likely encrypted or random bytes).
+func Classify(b []byte) Classification {
+	h := Entropy(b)
+	switch {
+	case h < 4.5 && IsPrintableText(b):
+		return ClassText
+	case h <= 7.0:
+		return ClassStructured
+	default:
+		return ClassRandom
+	}
+}
+
+// MagicType sniffs b's leading bytes for common structured-file signatures
+// (gzip, zip, ELF, PE) or a JSON/XML prefix, returning "" if none match.
+func MagicType(b []byte) string {
+	for _, sig := range magicSignatures {
+		if bytes.HasPrefix(b, sig.magic) {
+			return sig.name
+		}
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")), bytes.HasPrefix(trimmed, []byte("[")):
+		return "json"
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return "xml"
+	default:
+		return ""
+	}
+}
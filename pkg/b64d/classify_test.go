@@ -0,0 +1,95 @@
+package b64d
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want float64
+	}{
+		{"empty", nil, 0},
+		{"single repeated byte", []byte("aaaaaaaa"), 0},
+		{"two symbols evenly split", []byte("aabb"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Entropy(tt.in)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Entropy(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	random := make([]byte, 256)
+	for i := range random {
+		random[i] = byte(i)
+	}
+
+	tests := []struct {
+		name string
+		in   []byte
+		want Classification
+	}{
+		{"plain text", []byte("the quick brown fox jumps over the lazy dog"), ClassText},
+		{"byte-value ramp is near-uniform", random, ClassRandom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.in); got != tt.want {
+				t.Errorf("Classify(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMagicType(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+	}{
+		{"gzip", []byte{0x1f, 0x8b, 0x08, 0x00}, "gzip"},
+		{"zip", []byte{0x50, 0x4b, 0x03, 0x04}, "zip"},
+		{"elf", []byte{0x7f, 0x45, 0x4c, 0x46}, "elf"},
+		{"pe", []byte{0x4d, 0x5a, 0x90, 0x00}, "pe"},
+		{"json object", []byte(`{"a":1}`), "json"},
+		{"json array", []byte(`[1,2,3]`), "json"},
+		{"xml", []byte(`<root/>`), "xml"},
+		{"plain text has no magic", []byte("hello"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MagicType(tt.in); got != tt.want {
+				t.Errorf("MagicType(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerAccept(t *testing.T) {
+	random := make([]byte, 64)
+	for i := range random {
+		random[i] = byte(i * 53 % 256)
+	}
+
+	raw := base64Of(string(random))
+	s := NewScanner(strings.NewReader(raw), Options{Accept: []string{"random", "structured"}})
+
+	m, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next() error: %v", err)
+	}
+	if m.Classification != ClassRandom && m.Classification != ClassStructured {
+		t.Errorf("got classification %v, want random or structured", m.Classification)
+	}
+}
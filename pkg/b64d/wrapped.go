@@ -0,0 +1,189 @@
+package b64d
+
+import (
+	"bytes"
+	"regexp"
+)
+
+var (
+	pemBeginPattern      = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----`)
+	pemEndPattern        = regexp.MustCompile(`-----END [A-Z0-9 ]+-----`)
+	mimeB64HeaderPattern = regexp.MustCompile(`(?i)^Content-Transfer-Encoding:\s*base64\s*$`)
+)
+
+// line is a byte-offset-tagged slice of content split on '\n', with any
+// trailing '\r' stripped.
+type line struct {
+	start int
+	text  []byte
+}
+
+func splitLines(content []byte) []line {
+	var lines []line
+	start := 0
+	for i, b := range content {
+		if b == '\n' {
+			lines = append(lines, line{start: start, text: bytes.TrimSuffix(content[start:i], []byte("\r"))})
+			start = i + 1
+		}
+	}
+	if start < len(content) {
+		lines = append(lines, line{start: start, text: bytes.TrimSuffix(content[start:], []byte("\r"))})
+	}
+	return lines
+}
+
+// findWrappedBase64Blocks finds base64 payloads wrapped across multiple
+// lines (PEM bodies, MIME base64 sections, `base64 -w76` output) and
+// assembles each block into a single whitespace-stripped candidate, so the
+// whole payload decodes as one unit instead of each wrapped line being tried
+// in isolation. The second return value lists the [start, end) byte ranges
+// each assembled block consumed, so callers can suppress the overlapping
+// per-line candidates that would otherwise also be reported for the same
+// bytes.
+func findWrappedBase64Blocks(content []byte, minLength int) ([]candidate, [][2]int) {
+	lines := splitLines(content)
+	consumed := make([]bool, len(lines))
+	var matches []candidate
+	var covered [][2]int
+
+	cover := func(from, to int) { // inclusive line indices
+		end := len(content)
+		if to+1 < len(lines) {
+			end = lines[to+1].start
+		}
+		covered = append(covered, [2]int{lines[from].start, end})
+	}
+
+	for i, l := range lines {
+		if consumed[i] || !pemBeginPattern.Match(l.text) {
+			continue
+		}
+		end, ok := findPEMEnd(lines, i+1)
+		if !ok {
+			continue
+		}
+		if c, ok := assembleBlock(lines[i+1:end], minLength); ok {
+			matches = append(matches, c)
+			cover(i, end)
+		}
+		for j := i; j <= end; j++ {
+			consumed[j] = true
+		}
+	}
+
+	for i, l := range lines {
+		if consumed[i] || !mimeB64HeaderPattern.Match(bytes.TrimSpace(l.text)) {
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !consumed[end] && len(bytes.TrimSpace(lines[end].text)) > 0 {
+			end++
+		}
+		if c, ok := assembleBlock(lines[i+1:end], minLength); ok {
+			matches = append(matches, c)
+			cover(i, end-1)
+		}
+		for j := i; j < end; j++ {
+			consumed[j] = true
+		}
+	}
+
+	// No explicit PEM/MIME delimiter: fall back to a heuristic run of
+	// lines that are almost entirely base64 alphabet.
+	for i := 0; i < len(lines); i++ {
+		if consumed[i] || !isMostlyBase64Line(lines[i].text) {
+			continue
+		}
+		j := i
+		for j < len(lines) && !consumed[j] && isMostlyBase64Line(lines[j].text) {
+			j++
+		}
+		if j-i >= 2 {
+			if c, ok := assembleBlock(lines[i:j], minLength); ok {
+				matches = append(matches, c)
+				cover(i, j-1)
+			}
+		}
+		i = j - 1
+	}
+
+	return matches, covered
+}
+
+func findPEMEnd(lines []line, from int) (int, bool) {
+	for i := from; i < len(lines); i++ {
+		if pemEndPattern.Match(lines[i].text) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// assembleBlock strips ASCII whitespace from and concatenates a run of
+// lines into a single base64 candidate, starting at the first non-blank
+// line's offset.
+func assembleBlock(lines []line, minLength int) (candidate, bool) {
+	var buf bytes.Buffer
+	start := -1
+	for _, l := range lines {
+		stripped := stripASCIIWhitespace(l.text)
+		if len(stripped) == 0 {
+			continue
+		}
+		if start < 0 {
+			start = l.start
+		}
+		buf.Write(stripped)
+	}
+
+	raw := buf.String()
+	if len(raw) < minLength || !isValidBase64Length(raw) {
+		return candidate{}, false
+	}
+	return candidate{raw: raw, start: start, encoding: "b64"}, true
+}
+
+// isMostlyBase64Line reports whether at least 90% of a line's non-space
+// characters belong to the base64 alphabet, flagging it as part of a
+// wrapped block rather than unrelated surrounding text.
+func isMostlyBase64Line(text []byte) bool {
+	nonSpace := stripASCIIWhitespace(text)
+	if len(nonSpace) == 0 {
+		return false
+	}
+
+	base64Count := 0
+	for _, c := range nonSpace {
+		if isBase64AlphabetByte(c) {
+			base64Count++
+		}
+	}
+	return float64(base64Count)/float64(len(nonSpace)) >= 0.9
+}
+
+func isBase64AlphabetByte(c byte) bool {
+	switch {
+	case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		return true
+	case c == '+' || c == '/' || c == '=':
+		return true
+	default:
+		return false
+	}
+}
+
+// stripASCIIWhitespace removes spaces, tabs, and CR/LF, mirroring what Go's
+// base64.NewDecoder newline filter does for wrapped input.
+func stripASCIIWhitespace(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package b64d
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+// wrap76 mimics `base64 -w76`: it inserts a newline every 76 characters.
+func wrap76(s string) string {
+	var b strings.Builder
+	for len(s) > 76 {
+		b.WriteString(s[:76])
+		b.WriteByte('\n')
+		s = s[76:]
+	}
+	b.WriteString(s)
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func TestScannerWrappedBase64(t *testing.T) {
+	payload := strings.Repeat("the quick brown fox jumps over the lazy dog. ", 4)
+	encoded := base64.StdEncoding.EncodeToString([]byte(payload))
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{
+			name:  "PEM envelope",
+			input: "-----BEGIN CERTIFICATE-----\n" + wrap76(encoded) + "-----END CERTIFICATE-----\n",
+		},
+		{
+			name:  "MIME content-transfer-encoding section",
+			input: "Content-Type: application/octet-stream\nContent-Transfer-Encoding: base64\n\n" + wrap76(encoded) + "\n",
+		},
+		{
+			name:  "bare base64 -w76 wrapped block",
+			input: "here is a payload, encoded below:\n\n" + wrap76(encoded) + "\nend of payload.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewScanner(strings.NewReader(tt.input), Options{})
+
+			found := false
+			for {
+				m, err := s.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next() error: %v", err)
+				}
+				if string(m.Decoded) == payload {
+					found = true
+				}
+			}
+			if !found {
+				t.Fatalf("wrapped block was not assembled and decoded to the original payload")
+			}
+		})
+	}
+}
+
+// TestScannerWrappedBase64SuppressesLineFragments guards against the
+// assembled block being reported alongside, rather than instead of, the
+// per-line fragments findBase64Patterns also finds in its wrapped lines.
+func TestScannerWrappedBase64SuppressesLineFragments(t *testing.T) {
+	payload := strings.Repeat("the quick brown fox jumps over the lazy dog. ", 4)
+	encoded := base64.StdEncoding.EncodeToString([]byte(payload))
+	input := "here is a payload, encoded below:\n\n" + wrap76(encoded) + "\nend of payload.\n"
+
+	s := NewScanner(strings.NewReader(input), Options{})
+
+	whole := 0
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error: %v", err)
+		}
+		if m.Encoding != "b64" {
+			continue
+		}
+		if m.Raw == encoded {
+			whole++
+			continue
+		}
+		if strings.Contains(encoded, m.Raw) {
+			t.Fatalf("wrapped block line was also reported as its own fragment: %q", m.Raw)
+		}
+	}
+	if whole != 1 {
+		t.Fatalf("got %d whole-block matches, want exactly 1", whole)
+	}
+}
+
+func TestAssembleBlockRejectsBelowMinLength(t *testing.T) {
+	lines := []line{{start: 0, text: []byte("YWI=")}}
+	if _, ok := assembleBlock(lines, 100); ok {
+		t.Fatalf("expected assembleBlock to reject a block shorter than minLength")
+	}
+}